@@ -0,0 +1,120 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func TestScanContinuation(t *testing.T) {
+	input := "# envctl: provider=gsm \\\n    gsm_project=526782592\nline 3\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Scan() got %d modelines, want 1: %+v", len(got), got)
+	}
+
+	if got[0].Options["provider"] != "gsm" || got[0].Options["gsm_project"] != "526782592" {
+		t.Errorf("Options = %+v, want provider=gsm gsm_project=526782592", got[0].Options)
+	}
+
+	if got[0].StartLine != 1 || got[0].EndLine != 2 {
+		t.Errorf("StartLine/EndLine = %d/%d, want 1/2", got[0].StartLine, got[0].EndLine)
+	}
+}
+
+func TestScanContinuationChained(t *testing.T) {
+	input := "# envctl: provider=gsm \\\n  a=1 \\\n  b=2\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["a"] != "1" || got[0].Options["b"] != "2" {
+		t.Fatalf("Scan() = %+v, want a chained three-line modeline", got)
+	}
+
+	if got[0].StartLine != 1 || got[0].EndLine != 3 {
+		t.Errorf("StartLine/EndLine = %d/%d, want 1/3", got[0].StartLine, got[0].EndLine)
+	}
+}
+
+func TestScanContinuationEscapedBackslashDoesNotContinue(t *testing.T) {
+	input := `# envctl: path=C:\\ other=1` + "\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].StartLine != got[0].EndLine {
+		t.Fatalf("Scan() = %+v, want a single non-continued line", got)
+	}
+}
+
+func TestScanContinuationEvenTrailingBackslashesDoNotContinue(t *testing.T) {
+	input := `# envctl: path=C:\\` + "\nline 2\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].StartLine != got[0].EndLine {
+		t.Fatalf("Scan() = %+v, want a single non-continued line: a trailing doubled backslash is literal, not a continuation", got)
+	}
+}
+
+func TestScanContinuationAcrossBottomWindow(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		b.WriteString("filler line\n")
+	}
+
+	b.WriteString("# envctl: provider=gsm \\\n    gsm_project=526782592\n")
+
+	s := modeline.Scanner{ScanTop: false, ScanBottom: true, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["gsm_project"] != "526782592" {
+		t.Fatalf("Scan() = %+v, want the continued bottom modeline", got)
+	}
+}
+
+func TestScanContinuationOpenerStraddlesBottomWindow(t *testing.T) {
+	// The continuation's opening line sits 6 physical lines from EOF, outside
+	// a naive last-5-lines window, but its continuation line falls inside it.
+	// The opener must not be evicted out from under the continuation.
+	input := "filler line\n" +
+		"# envctl: provider=gsm \\\n" +
+		"    gsm_project=526782592\n" +
+		"filler line\nfiller line\nfiller line\nfiller line\n"
+
+	s := modeline.Scanner{ScanTop: false, ScanBottom: true, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["gsm_project"] != "526782592" {
+		t.Fatalf("Scan() = %+v, want the continued modeline whose opener straddles the bottom window boundary", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Form selects the modeline syntax emitted by Modeline.Format.
+type Form int
+
+const (
+	// FormFirst emits the first form, e.g. "vim: sw=3 foldmethod=marker".
+	FormFirst Form = iota
+	// FormSecond emits the second form, e.g. "vim:set sw=3 foldmethod=marker:".
+	FormSecond
+)
+
+// Format renders m as a modeline string of the given form, prefixed with
+// comment (e.g. "#" or "//"). If comment opens a block comment ("/*"), a
+// matching " */" is appended automatically.
+//
+// Options are emitted in the order given by m.OptionOrder, falling back to
+// sorted key order when OptionOrder is unset or incomplete. A value of
+// exactly "true" is emitted as the implicit-boolean "key" form, except for
+// a key beginning with "no", which is emitted as "key=true". A value of
+// exactly "false" is emitted as "noKey" where ScanString parses that back
+// to key="false" (see negationRoundTrips), and as "key=false" otherwise.
+// Keys containing whitespace, ':' or '=' cannot
+// be round-tripped by the parser and are rejected.
+func (m *Modeline) Format(form Form, comment string) (string, error) {
+	if m.Program == "" {
+		return "", errors.New("modeline: cannot format a Modeline without a Program")
+	}
+
+	tokens, err := m.formatTokens()
+	if err != nil {
+		return "", err
+	}
+
+	var body string
+	switch form {
+	case FormFirst:
+		body = formatFirstForm(m.Program, tokens)
+	case FormSecond:
+		body = formatSecondForm(m.Program, tokens)
+	default:
+		return "", fmt.Errorf("modeline: unknown form %d", form)
+	}
+
+	out := comment + " " + body
+	if strings.HasPrefix(comment, "/*") && !strings.HasSuffix(out, "*/") {
+		out += " */"
+	}
+
+	return out, nil
+}
+
+// formatTokens converts m.Options into parser-compatible tokens, in
+// m.OptionOrder followed by any keys it omits, in sorted order.
+func (m *Modeline) formatTokens() ([]string, error) {
+	keys := append([]string(nil), m.OptionOrder...)
+	listed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		listed[key] = struct{}{}
+	}
+
+	var unlisted []string
+	for key := range m.Options {
+		if _, ok := listed[key]; !ok {
+			unlisted = append(unlisted, key)
+		}
+	}
+
+	sort.Strings(unlisted)
+	keys = append(keys, unlisted...)
+
+	tokens := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, ok := m.Options[key]
+		if !ok {
+			continue
+		}
+
+		if strings.ContainsAny(key, " \t:=") {
+			return nil, fmt.Errorf("modeline: option key %q contains whitespace, ':' or '=' and cannot be formatted", key)
+		}
+
+		if strings.ContainsAny(value, " \t:\n") {
+			return nil, fmt.Errorf("modeline: value %q for option %q contains whitespace or ':' and cannot be formatted", value, key)
+		}
+
+		switch {
+		case value == "true" && !(strings.HasPrefix(key, "no") && len(key) > 2):
+			tokens = append(tokens, key)
+		case value == "false" && negationRoundTrips(m.Program, key):
+			tokens = append(tokens, "no"+key)
+		default:
+			tokens = append(tokens, key+"="+value)
+		}
+	}
+
+	return tokens, nil
+}
+
+// negationRoundTrips reports whether emitting "noKey" for a "false" value
+// would parse back to key="false", matching parseOption's schema-aware
+// negation: true when program has no registered schema (no ambiguity to
+// resolve) or when key is declared there as OptionBool. Otherwise
+// ScanString would read "noKey" back as the literal key "noKey"="true",
+// breaking Format's round-trip guarantee.
+func negationRoundTrips(program, key string) bool {
+	known, hasSchema := schemaHasOption(program, key)
+	return !hasSchema || known
+}
+
+func formatFirstForm(program string, tokens []string) string {
+	if len(tokens) == 0 {
+		return program + ":"
+	}
+
+	return program + ": " + strings.Join(tokens, " ")
+}
+
+func formatSecondForm(program string, tokens []string) string {
+	if len(tokens) == 0 {
+		return program + ":set :"
+	}
+
+	return program + ":set " + strings.Join(tokens, " ") + ":"
+}
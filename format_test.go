@@ -0,0 +1,178 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       modeline.Modeline
+		form    modeline.Form
+		comment string
+		want    string
+	}{
+		{
+			name: "first form",
+			m: modeline.Modeline{
+				Program:     "vim",
+				Options:     map[string]string{"sw": "3", "foldmethod": "marker"},
+				OptionOrder: []string{"sw", "foldmethod"},
+			},
+			form:    modeline.FormFirst,
+			comment: "#",
+			want:    "# vim: sw=3 foldmethod=marker",
+		},
+		{
+			name: "second form with block comment",
+			m: modeline.Modeline{
+				Program:     "vim",
+				Options:     map[string]string{"sw": "3", "foldmethod": "marker"},
+				OptionOrder: []string{"sw", "foldmethod"},
+			},
+			form:    modeline.FormSecond,
+			comment: "/*",
+			want:    "/* vim:set sw=3 foldmethod=marker: */",
+		},
+		{
+			name: "implicit boolean round trip",
+			m: modeline.Modeline{
+				Program:     "vim",
+				Options:     map[string]string{"ai": "false", "cursorline": "true"},
+				OptionOrder: []string{"ai", "cursorline"},
+			},
+			form:    modeline.FormFirst,
+			comment: "#",
+			want:    "# vim: noai cursorline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Format(tt.form, tt.comment)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRejectsUnsafeKeys(t *testing.T) {
+	m := modeline.Modeline{
+		Program: "vim",
+		Options: map[string]string{"bad key": "1"},
+	}
+
+	if _, err := m.Format(modeline.FormFirst, "#"); err == nil {
+		t.Fatal("Format() error = nil, want an error for a key containing whitespace")
+	}
+}
+
+func TestFormatRejectsUnsafeValues(t *testing.T) {
+	m := modeline.Modeline{
+		Program: "vim",
+		Options: map[string]string{"title": "my title"},
+	}
+
+	if _, err := m.Format(modeline.FormFirst, "#"); err == nil {
+		t.Fatal("Format() error = nil, want an error for a value containing whitespace")
+	}
+}
+
+// TestRoundTrip verifies that formatting a Modeline and scanning it back
+// reproduces the same Program and Options, across the corpus of option
+// shapes exercised by TestScanString.
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		m    modeline.Modeline
+	}{
+		{
+			name: "key=value pairs",
+			m: modeline.Modeline{
+				Program:     "vim",
+				Options:     map[string]string{"sw": "3", "foldmethod": "marker"},
+				OptionOrder: []string{"sw", "foldmethod"},
+			},
+		},
+		{
+			name: "implicit booleans",
+			m: modeline.Modeline{
+				Program:     "vim",
+				Options:     map[string]string{"ai": "false", "cursorline": "true"},
+				OptionOrder: []string{"ai", "cursorline"},
+			},
+		},
+		{
+			name: "envctl provider options",
+			m: modeline.Modeline{
+				Program:     "roundtripprog",
+				Options:     map[string]string{"provider": "gsm", "gsm_project": "526782592"},
+				OptionOrder: []string{"provider", "gsm_project"},
+			},
+		},
+		{
+			name: "no options",
+			m: modeline.Modeline{
+				Program: "robot",
+				Options: map[string]string{},
+			},
+		},
+		{
+			name: "schema-registered program with a non-bool negated key",
+			m: func() modeline.Modeline {
+				modeline.RegisterSchema("roundtripschema", modeline.Schema{
+					Options: map[string]modeline.OptionSchema{
+						"verbose": {Type: modeline.OptionBool},
+						"retries": {Type: modeline.OptionInt},
+					},
+				})
+
+				return modeline.Modeline{
+					Program:     "roundtripschema",
+					Options:     map[string]string{"verbose": "false", "retries": "false"},
+					OptionOrder: []string{"verbose", "retries"},
+				}
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		for _, form := range []modeline.Form{modeline.FormFirst, modeline.FormSecond} {
+			t.Run(tt.name, func(t *testing.T) {
+				formatted, err := tt.m.Format(form, "#")
+				if err != nil {
+					t.Fatalf("Format() error = %v", err)
+				}
+
+				got, err := modeline.ScanString(formatted)
+				if err != nil {
+					t.Fatalf("ScanString(%q) error = %v", formatted, err)
+				}
+
+				if got.Program != tt.m.Program {
+					t.Errorf("Program = %q, want %q", got.Program, tt.m.Program)
+				}
+
+				if len(got.Options) != len(tt.m.Options) {
+					t.Fatalf("got %d options, want %d (formatted: %q)", len(got.Options), len(tt.m.Options), formatted)
+				}
+
+				for k, v := range tt.m.Options {
+					if got.Options[k] != v {
+						t.Errorf("Options[%q] = %q, want %q", k, got.Options[k], v)
+					}
+				}
+			})
+		}
+	}
+}
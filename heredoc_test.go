@@ -0,0 +1,107 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func TestScanHeredocBlock(t *testing.T) {
+	input := "line before\n# envctl<<END\nprovider=gsm\ngsm_project=526782592\nEND\nline after\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Scan() got %d modelines, want 1: %+v", len(got), got)
+	}
+
+	if got[0].Program != "envctl" {
+		t.Errorf("Program = %q, want %q", got[0].Program, "envctl")
+	}
+
+	want := map[string]string{"provider": "gsm", "gsm_project": "526782592"}
+	for k, v := range want {
+		if got[0].Options[k] != v {
+			t.Errorf("Options[%q] = %q, want %q", k, got[0].Options[k], v)
+		}
+	}
+
+	wantRaw := "# envctl<<END\nprovider=gsm\ngsm_project=526782592\nEND"
+	if got[0].RawLine != wantRaw {
+		t.Errorf("RawLine = %q, want %q", got[0].RawLine, wantRaw)
+	}
+}
+
+func TestScanHeredocBlockTrimmed(t *testing.T) {
+	input := "# envctl<<-END\n    provider=gsm\n    gsm_project=526782592\nEND\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["provider"] != "gsm" || got[0].Options["gsm_project"] != "526782592" {
+		t.Fatalf("Scan() = %+v, want a single envctl modeline", got)
+	}
+}
+
+func TestScanHeredocUnclosedFallsBackToLineScanning(t *testing.T) {
+	input := "# envctl<<END\nline 2\nline 3\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 3}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("Scan() got %d modelines, want 0 for an unclosed block: %+v", len(got), got)
+	}
+}
+
+func TestScanHeredocCountsTowardMaxLines(t *testing.T) {
+	input := "# envctl<<END\nprovider=gsm\nEND\nline 4\nline 5\nline 6\n# vim: sw=4\n"
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 3}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	// The block consumes 3 physical lines (opener, body, marker), exhausting
+	// MaxLines; the later "# vim: sw=4" line falls outside the window.
+	if len(got) != 1 || got[0].Program != "envctl" {
+		t.Fatalf("Scan() = %+v, want only the envctl block", got)
+	}
+}
+
+func TestScanHeredocOpenerStraddlesBottomWindow(t *testing.T) {
+	// The heredoc's opener sits 6 physical lines from EOF, outside a naive
+	// last-5-lines window, but its closing marker falls inside it. The
+	// opener must not be evicted out from under the block.
+	input := "filler line\n" +
+		"# envctl<<END\n" +
+		"provider=gsm\n" +
+		"END\n" +
+		"filler line\nfiller line\nfiller line\n"
+
+	s := modeline.Scanner{ScanTop: false, ScanBottom: true, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["provider"] != "gsm" {
+		t.Fatalf("Scan() = %+v, want the heredoc block whose opener straddles the bottom window boundary", got)
+	}
+}
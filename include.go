@@ -0,0 +1,172 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// defaultMaxIncludeDepth is used when Scanner.MaxIncludeDepth is left zero.
+const defaultMaxIncludeDepth = 8
+
+// maxIncludeDepth returns s.MaxIncludeDepth, or defaultMaxIncludeDepth when unset.
+func (s *Scanner) maxIncludeDepth() int {
+	if s.MaxIncludeDepth <= 0 {
+		return defaultMaxIncludeDepth
+	}
+
+	return s.MaxIncludeDepth
+}
+
+// withDefaultIncludeResolver returns a shallow copy of s with IncludeResolver
+// set, if it wasn't already, to one that resolves paths relative to the
+// directory of fromFile.
+func (s *Scanner) withDefaultIncludeResolver(fromFile string) Scanner {
+	effective := *s
+	if effective.IncludeResolver != nil {
+		return effective
+	}
+
+	return effective.rebaseIncludeResolver(fromFile)
+}
+
+// rebaseIncludeResolver returns a copy of s with IncludeResolver replaced
+// unconditionally by one that resolves paths relative to the directory of
+// fromFile, recording that directory in includeDir. Unlike
+// withDefaultIncludeResolver, it always replaces the resolver, which is what
+// lets resolveInclude rebase a nested include against the directory of the
+// file that contains it rather than the original root file.
+func (s *Scanner) rebaseIncludeResolver(fromFile string) Scanner {
+	effective := *s
+
+	dir := filepath.Dir(fromFile)
+	effective.includeDir = dir
+	effective.IncludeResolver = func(path string) (io.ReadCloser, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		return os.Open(path)
+	}
+
+	return effective
+}
+
+// resolveIncludeOptions checks options for a reserved "include" key and, if
+// present, removes it and merges in the options it resolves to (see
+// resolveInclude), without overwriting any key options already has
+// explicitly. It is a no-op when options has no "include" key. Every
+// modeline form (plain lines, continuations and heredoc blocks) routes
+// through this before applySchema, so "include" is resolved consistently
+// regardless of which form it was written in.
+func (s *Scanner) resolveIncludeOptions(program string, options map[string]string) error {
+	includePath, ok := options["include"]
+	if !ok {
+		return nil
+	}
+
+	delete(options, "include")
+
+	included, err := s.resolveInclude(program, includePath, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range included {
+		if _, exists := options[k]; !exists {
+			options[k] = v
+		}
+	}
+
+	return nil
+}
+
+// resolveInclude opens the file referenced by an "include" option and
+// returns the Options of the first modeline within it whose Program
+// matches program, so they can be merged underneath the including
+// modeline's explicit keys. visited guards against include cycles and
+// depth is checked against MaxIncludeDepth; both propagate through nested
+// includes. A missing match in the included file is not an error — it
+// simply contributes nothing to merge.
+func (s *Scanner) resolveInclude(program, path string, visited map[string]struct{}, depth int) (map[string]string, error) {
+	if depth >= s.maxIncludeDepth() {
+		return nil, fmt.Errorf("modeline: include of %q exceeds MaxIncludeDepth (%d)", path, s.maxIncludeDepth())
+	}
+
+	if s.IncludeResolver == nil {
+		return nil, fmt.Errorf("modeline: encountered include=%q with no IncludeResolver set", path)
+	}
+
+	if _, seen := visited[path]; seen {
+		return nil, fmt.Errorf("modeline: include cycle detected at %q", path)
+	}
+
+	visitedNext := make(map[string]struct{}, len(visited)+1)
+	for k := range visited {
+		visitedNext[k] = struct{}{}
+	}
+	visitedNext[path] = struct{}{}
+
+	rc, err := s.IncludeResolver(path)
+	if err != nil {
+		return nil, fmt.Errorf("modeline: opening include %q: %w", path, err)
+	}
+	defer rc.Close()
+
+	lineScanner := bufio.NewScanner(rc)
+	for lineScanner.Scan() {
+		p, rest, err := findProgram(lineScanner.Text())
+		if err != nil || p != program {
+			continue
+		}
+
+		options, err := parseOptions(p, rest)
+		if err != nil {
+			continue
+		}
+
+		merged := make(map[string]string, len(options))
+		for k, v := range options {
+			merged[k] = v
+		}
+
+		if nestedPath, ok := merged["include"]; ok {
+			delete(merged, "include")
+
+			nestedScanner := s
+			if s.includeDir != "" {
+				fullPath := path
+				if !filepath.IsAbs(fullPath) {
+					fullPath = filepath.Join(s.includeDir, fullPath)
+				}
+
+				rebased := s.rebaseIncludeResolver(fullPath)
+				nestedScanner = &rebased
+			}
+
+			nested, err := nestedScanner.resolveInclude(program, nestedPath, visitedNext, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			for k, v := range nested {
+				if _, exists := merged[k]; !exists {
+					merged[k] = v
+				}
+			}
+		}
+
+		return merged, nil
+	}
+
+	if err := lineScanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{}, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func testdataResolver(path string) (io.ReadCloser, error) {
+	return os.Open("testdata/" + path)
+}
+
+func TestScanStringInclude(t *testing.T) {
+	s := modeline.Scanner{IncludeResolver: testdataResolver}
+
+	got, err := s.ScanString("# envctl: include=shared.modeline provider=vault")
+	if err != nil {
+		t.Fatalf("ScanString() error = %v", err)
+	}
+
+	if got.Options["provider"] != "vault" {
+		t.Errorf(`Options["provider"] = %q, want explicit "vault" to win over the include`, got.Options["provider"])
+	}
+
+	if got.Options["region"] != "us-east1" {
+		t.Errorf(`Options["region"] = %q, want %q merged from the include`, got.Options["region"], "us-east1")
+	}
+
+	if _, ok := got.Options["include"]; ok {
+		t.Error(`Options still contains the reserved "include" directive key`)
+	}
+}
+
+func TestScanStringIncludeNoResolver(t *testing.T) {
+	_, err := modeline.ScanString("# envctl: include=shared.modeline")
+	if err == nil {
+		t.Fatal("ScanString() error = nil, want an error since the default Scanner has no IncludeResolver")
+	}
+}
+
+func TestScanStringIncludeCycle(t *testing.T) {
+	s := modeline.Scanner{IncludeResolver: testdataResolver}
+
+	_, err := s.ScanString("# envctl: include=cyclic_a.modeline")
+	if err == nil {
+		t.Fatal("ScanString() error = nil, want a cycle detection error")
+	}
+}
+
+func TestScanStringIncludeMaxDepth(t *testing.T) {
+	s := modeline.Scanner{IncludeResolver: testdataResolver, MaxIncludeDepth: 1}
+
+	_, err := s.ScanString("# envctl: include=cyclic_a.modeline")
+	if err == nil {
+		t.Fatal("ScanString() error = nil, want a MaxIncludeDepth error")
+	}
+}
+
+func TestScanHeredocInclude(t *testing.T) {
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5, IncludeResolver: testdataResolver}
+
+	input := "# envctl<<END\ninclude=shared.modeline\nprovider=vault\nEND\n"
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Scan() got %d modelines, want 1: %+v", len(got), got)
+	}
+
+	if got[0].Options["provider"] != "vault" {
+		t.Errorf(`Options["provider"] = %q, want explicit "vault" to win over the include`, got[0].Options["provider"])
+	}
+
+	if got[0].Options["region"] != "us-east1" {
+		t.Errorf(`Options["region"] = %q, want %q merged from the include`, got[0].Options["region"], "us-east1")
+	}
+
+	if _, ok := got[0].Options["include"]; ok {
+		t.Error(`Options still contains the reserved "include" directive key`)
+	}
+}
+
+func TestScanFileIncludeResolvesNestedIncludeRelativeToItsOwnDir(t *testing.T) {
+	s := modeline.Scanner{ScanTop: true, MaxLines: 5}
+
+	got, err := s.ScanFile("testdata/nested/a.modeline")
+	if err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["provider"] != "gsm" {
+		t.Fatalf("ScanFile() = %+v, want provider merged from sub/b.modeline's own include", got)
+	}
+
+	if got[0].Options["region"] != "us-west2" {
+		t.Errorf(`Options["region"] = %q, want %q merged from sub/c.modeline, resolved relative to sub/ rather than testdata/nested/`, got[0].Options["region"], "us-west2")
+	}
+}
+
+func TestScanFileIncludeResolvesRelativeToFile(t *testing.T) {
+	s := modeline.Scanner{ScanTop: true, MaxLines: 5}
+
+	got, err := s.ScanFile("testdata/shared.modeline")
+	if err != nil {
+		t.Fatalf("ScanFile() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["provider"] != "gsm" {
+		t.Fatalf("ScanFile() = %+v, want the envctl modeline from shared.modeline", got)
+	}
+}
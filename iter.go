@@ -0,0 +1,155 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"os"
+)
+
+// All returns an iterator that lazily scans r and yields each Modeline (or
+// an error) as it's discovered, without allocating a result slice. Top-of-file
+// modelines are yielded as soon as their window is parsed; bottom-of-file
+// modelines still require buffering the tail of r, so
+// they are only yielded once r is exhausted. Callers that only need the
+// first few matches (e.g. linters checking Program) can stop ranging early
+// to skip the remaining work entirely.
+//
+// Scan is a thin wrapper that collects All into a slice for callers that
+// don't need lazy iteration.
+func (s *Scanner) All(r io.Reader) iter.Seq2[Modeline, error] {
+	return func(yield func(Modeline, error) bool) {
+		if !s.ScanTop && !s.ScanBottom {
+			return
+		}
+
+		scanner := bufio.NewScanner(r)
+
+		// Optimize for top-only scanning: read and parse only MaxLines (plus any
+		// heredoc block or continuation still open at the window's end), then stop
+		if s.ScanTop && !s.ScanBottom {
+			topLines, _, err := readTopWindow(scanner, s.MaxLines)
+			if err != nil {
+				yield(Modeline{}, err)
+				return
+			}
+
+			for _, m := range s.scanLines(topLines, 0) {
+				if !yield(m, nil) {
+					return
+				}
+			}
+
+			return
+		}
+
+		// For bottom-only or both: buffer the tail of the file, evicting
+		// down to MaxLines as new lines arrive.
+		var bottomBuffer []string
+		lineCount := 0
+
+		// If scanning top, parse and yield the first MaxLines immediately
+		if s.ScanTop {
+			topLines, n, err := readTopWindow(scanner, s.MaxLines)
+			if err != nil {
+				yield(Modeline{}, err)
+				return
+			}
+
+			lineCount = n
+
+			for _, m := range s.scanLines(topLines, 0) {
+				if !yield(m, nil) {
+					return
+				}
+			}
+		}
+
+		// Continue reading remaining lines into the buffer for bottom
+		// scanning. Eviction only drops whole, fully-resolved heredoc blocks
+		// or continuation chains (see frontSegmentLength) and only once
+		// MaxLines lines would still remain afterward — a block/chain whose
+		// opening line would otherwise be evicted before it resolves is
+		// instead kept, letting the buffer grow past MaxLines temporarily
+		// so it isn't split across the eviction boundary.
+		for scanner.Scan() {
+			line := scanner.Text()
+			bottomBuffer = append(bottomBuffer, line)
+			lineCount++
+
+			for len(bottomBuffer) > s.MaxLines {
+				segLen, resolved := frontSegmentLength(bottomBuffer)
+				if !resolved || len(bottomBuffer)-segLen < s.MaxLines {
+					break
+				}
+
+				copy(bottomBuffer, bottomBuffer[segLen:])
+				bottomBuffer = bottomBuffer[:len(bottomBuffer)-segLen]
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			yield(Modeline{}, err)
+			return
+		}
+
+		// Parse bottom lines from buffer
+		if !s.ScanBottom {
+			return
+		}
+
+		if s.ScanTop && lineCount <= s.MaxLines {
+			// File is shorter than or equal to MaxLines, and we already scanned
+			// it from the top; the buffer below holds nothing new.
+			return
+		}
+
+		// bottomBuffer only ever holds lines read after readTopWindow's
+		// scanner left off, so it never re-reads (and never needs to skip)
+		// anything the top window already scanned.
+		lineOffset := lineCount - len(bottomBuffer)
+
+		for _, m := range s.scanLines(bottomBuffer, lineOffset) {
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AllFile returns an iterator like All, but reading from the file at path.
+// The file is opened and closed within the iteration; if it cannot be
+// opened, the error is yielded once with a zero Modeline. If IncludeResolver
+// is unset, one is provided automatically that resolves include paths
+// relative to path's directory.
+func (s *Scanner) AllFile(path string) iter.Seq2[Modeline, error] {
+	return func(yield func(Modeline, error) bool) {
+		file, err := os.Open(path)
+		if err != nil {
+			yield(Modeline{}, err)
+			return
+		}
+		defer file.Close()
+
+		effective := s.withDefaultIncludeResolver(path)
+
+		for m, err := range effective.All(file) {
+			if !yield(m, err) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over r using the default settings.
+func All(r io.Reader) iter.Seq2[Modeline, error] {
+	return defaultScanner.All(r)
+}
+
+// AllFile returns an iterator over the file at path using the default settings.
+func AllFile(path string) iter.Seq2[Modeline, error] {
+	return defaultScanner.AllFile(path)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func TestAll(t *testing.T) {
+	input := `# vim: sw=3
+line 2
+line 3
+line 4
+line 5
+line 6
+# envctl: provider=gsm
+`
+	s := modeline.Scanner{ScanTop: true, ScanBottom: true, MaxLines: 2}
+
+	var got []modeline.Modeline
+	for m, err := range s.All(strings.NewReader(input)) {
+		if err != nil {
+			t.Fatalf("All() error = %v", err)
+		}
+
+		got = append(got, m)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("All() got %d modelines, want 2: %+v", len(got), got)
+	}
+
+	if got[0].Program != "vim" || got[1].Program != "envctl" {
+		t.Errorf("Programs = %q, %q, want vim, envctl", got[0].Program, got[1].Program)
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	input := `# vim: sw=3
+# envctl: provider=gsm
+# vim: sw=4
+`
+	s := modeline.Scanner{ScanTop: true, ScanBottom: false, MaxLines: 5}
+
+	var seen int
+	for range s.All(strings.NewReader(input)) {
+		seen++
+		break
+	}
+
+	if seen != 1 {
+		t.Fatalf("expected iteration to stop after 1 modeline, saw %d", seen)
+	}
+}
+
+func TestScanMatchesAll(t *testing.T) {
+	input := testFileContent
+	s := modeline.Scanner{ScanTop: true, ScanBottom: true, MaxLines: 5}
+
+	viaScan, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	var viaAll []modeline.Modeline
+	for m, err := range s.All(strings.NewReader(input)) {
+		if err != nil {
+			t.Fatalf("All() error = %v", err)
+		}
+
+		viaAll = append(viaAll, m)
+	}
+
+	if len(viaScan) != len(viaAll) {
+		t.Fatalf("Scan() got %d modelines, All() got %d", len(viaScan), len(viaAll))
+	}
+}
+
+func BenchmarkAllEarlyBreak(b *testing.B) {
+	scanner := modeline.Scanner{
+		ScanTop:    true,
+		ScanBottom: true,
+		MaxLines:   5,
+	}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		reader := strings.NewReader(testFileContent)
+
+		for m, err := range scanner.All(reader) {
+			if err != nil {
+				b.Fatalf("All() error = %v", err)
+			}
+
+			if m.Program == "envctl" {
+				break
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strings"
 )
 
 var ErrNoModeline = errors.New("no modeline found")
@@ -17,6 +18,31 @@ type Modeline struct {
 	Program string            // The identifier (e.g., "vi", "vim", "envctl")
 	Options map[string]string // Parsed key=value options
 	RawLine string            // Original line text
+
+	// OptionOrder, if set, controls the order Format emits Options in.
+	// Scan/ScanString do not populate it; callers building a Modeline to
+	// format programmatically may set it to preserve insertion order,
+	// otherwise Format falls back to sorted key order.
+	OptionOrder []string
+
+	// Typed holds Options coerced to the Go types declared by a schema
+	// registered via RegisterSchema for Program. It is nil when no schema
+	// is registered.
+	Typed map[string]any
+	// Warnings lists options present in the modeline but not declared by
+	// the registered schema.
+	Warnings []string
+	// Errors lists schema violations, such as a missing required option
+	// or a value that could not be coerced to its declared type.
+	Errors []string
+
+	// StartLine and EndLine are the 1-based physical line numbers the
+	// modeline was read from. For a modeline spanning a backslash
+	// continuation or heredoc block, they mark the first and last physical
+	// line respectively; otherwise they're equal. Both are zero when the
+	// Modeline came from ScanString outside of a Scan/ScanFile call.
+	StartLine int
+	EndLine   int
 }
 
 // Scanner extracts modelines from files or text.
@@ -24,6 +50,25 @@ type Scanner struct {
 	ScanTop    bool // Scan from top of file.
 	ScanBottom bool // Scan from bottom of file.
 	MaxLines   int  // Lines to scan from each edge.
+
+	// IncludeResolver opens the file referenced by an "include" option
+	// (see ScanString). ScanFile sets this automatically, resolving paths
+	// relative to the file being scanned, when it is left nil. ScanString
+	// returns an error if it encounters an include with no resolver set.
+	// Scan, ScanFile and All return no error for an include that cannot be
+	// resolved; they skip that modeline, as they skip any line ScanString
+	// rejects.
+	IncludeResolver func(path string) (io.ReadCloser, error)
+	// MaxIncludeDepth bounds how many includes may chain before ScanString
+	// gives up and returns an error. Zero means the default of 8.
+	MaxIncludeDepth int
+
+	// includeDir is the directory the default IncludeResolver (see
+	// withDefaultIncludeResolver) resolves relative paths against. It is
+	// empty when IncludeResolver was supplied by the caller, in which case
+	// resolveInclude leaves nested includes resolving through the same
+	// resolver unchanged.
+	includeDir string
 }
 
 var defaultScanner = Scanner{
@@ -32,97 +77,303 @@ var defaultScanner = Scanner{
 	MaxLines:   5,
 }
 
-// Scan extracts modelines from the reader.
+// Scan extracts modelines from the reader. It is a thin wrapper collecting
+// All into a slice; callers that want to stop early (e.g. after the first
+// matching Program) should use All directly instead.
 func (s *Scanner) Scan(r io.Reader) ([]Modeline, error) {
-	// Early return if neither top nor bottom scanning is enabled
-	if !s.ScanTop && !s.ScanBottom {
-		return []Modeline{}, nil
+	var modelines []Modeline
+
+	for m, err := range s.All(r) {
+		if err != nil {
+			return nil, err
+		}
+
+		modelines = append(modelines, m)
 	}
 
-	scanner := bufio.NewScanner(r)
-	var modelines []Modeline
+	return modelines, nil
+}
 
-	// Optimize for top-only scanning: read and parse only MaxLines, then stop
-	if s.ScanTop && !s.ScanBottom {
-		lineCount := 0
-		for scanner.Scan() && lineCount < s.MaxLines {
-			line := scanner.Text()
-			if m, err := s.ScanString(line); err == nil {
-				modelines = append(modelines, *m)
+// readTopWindow reads up to maxLines lines from scanner. If the window ends
+// in the middle of an open heredoc block (see findHeredocOpen) or a
+// backslash continuation (see trailingBackslashOdd), reading continues past
+// maxLines, one physical line at a time, until the block/continuation
+// resolves or the reader is exhausted — both are only useful to scanLines
+// once complete. It returns the lines read and the total physical line
+// count consumed, which the caller uses to keep the bottom window's
+// accounting correct.
+func readTopWindow(scanner *bufio.Scanner, maxLines int) ([]string, int, error) {
+	var lines []string
+	lineCount := 0
+
+	for lineCount < maxLines && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		lineCount++
+	}
+
+	for {
+		if _, open := pendingHeredocMarker(lines); open {
+			if !scanner.Scan() {
+				break
 			}
 
+			lines = append(lines, scanner.Text())
 			lineCount++
+
+			continue
 		}
 
-		if err := scanner.Err(); err != nil {
-			return nil, err
+		if pendingContinuation(lines) {
+			if !scanner.Scan() {
+				break
+			}
+
+			lines = append(lines, scanner.Text())
+			lineCount++
+
+			continue
 		}
 
-		return modelines, nil
+		break
 	}
 
-	// For bottom-only or both: use circular buffer for bottom lines
-	bottomBuffer := make([]string, 0, s.MaxLines)
-	lineCount := 0
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return lines, lineCount, nil
+}
+
+// scanLines parses a window of consecutive raw lines numbered from
+// lineOffset+1, merging heredoc-style blocks (see findHeredocOpen) and
+// backslash-continued lines (see trailingBackslashOdd) into a single
+// Modeline and otherwise parsing each line independently via ScanString.
+func (s *Scanner) scanLines(lines []string, lineOffset int) []Modeline {
+	var modelines []Modeline
+
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+		lineNo := lineOffset + i + 1
+
+		if program, marker, trim, ok := findHeredocOpen(line); ok {
+			body, closeIdx, closed := collectHeredocBody(lines, i+1, marker)
+			if closed {
+				if trim {
+					body = stripCommonIndent(body)
+				}
 
-	// If scanning top, parse the first MaxLines immediately
-	if s.ScanTop {
-		for scanner.Scan() && lineCount < s.MaxLines {
-			line := scanner.Text()
-			if m, err := s.ScanString(line); err == nil {
-				modelines = append(modelines, *m)
+				options := parseFirstForm(program, strings.Join(body, "\n"))
+
+				if err := s.resolveIncludeOptions(program, options); err == nil {
+					raw := append([]string{line}, body...)
+					raw = append(raw, lines[closeIdx])
+
+					m := Modeline{
+						Program:   program,
+						Options:   options,
+						RawLine:   strings.Join(raw, "\n"),
+						StartLine: lineNo,
+						EndLine:   lineOffset + closeIdx + 1,
+					}
+					applySchema(&m)
+
+					modelines = append(modelines, m)
+				}
+
+				i = closeIdx + 1
+
+				continue
 			}
-			lineCount++
 		}
 
-		if err := scanner.Err(); err != nil {
-			return nil, err
+		if trailingBackslashOdd(line) {
+			if joined, endIdx := joinContinuationLines(lines, i); endIdx > i {
+				if m, err := s.ScanString(joined); err == nil {
+					m.StartLine = lineNo
+					m.EndLine = lineOffset + endIdx + 1
+					modelines = append(modelines, *m)
+				}
+
+				i = endIdx + 1
+
+				continue
+			}
 		}
+
+		if m, err := s.ScanString(line); err == nil {
+			m.StartLine = lineNo
+			m.EndLine = lineNo
+			modelines = append(modelines, *m)
+		}
+
+		i++
 	}
 
-	// Continue reading remaining lines into circular buffer for bottom scanning
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(bottomBuffer) < s.MaxLines {
-			bottomBuffer = append(bottomBuffer, line)
-		} else {
-			// Circular buffer: shift and add new line
-			copy(bottomBuffer, bottomBuffer[1:])
-			bottomBuffer[s.MaxLines-1] = line
+	return modelines
+}
+
+// trailingBackslashOdd reports whether s ends in an unescaped backslash: an
+// odd-length run of trailing '\\' characters. A doubled trailing backslash
+// ("\\\\") is an escaped, literal backslash and does not continue the line.
+func trailingBackslashOdd(s string) bool {
+	count := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		count++
+	}
+
+	return count%2 == 1
+}
+
+// pendingContinuation reports whether the last line in lines ends in an
+// unescaped backslash, meaning the window's final logical line is
+// incomplete and reading must continue.
+func pendingContinuation(lines []string) bool {
+	if len(lines) == 0 {
+		return false
+	}
+
+	return trailingBackslashOdd(lines[len(lines)-1])
+}
+
+// joinContinuationLines joins lines[start] with as many following lines as
+// are chained by trailing unescaped backslashes, stripping the backslash
+// and leading whitespace of each continuation. It returns the joined text
+// and the index of the last line consumed; if no continuation applied,
+// endIdx equals start.
+func joinContinuationLines(lines []string, start int) (joined string, endIdx int) {
+	text := lines[start]
+	i := start
+
+	for trailingBackslashOdd(text) && i+1 < len(lines) {
+		text = text[:len(text)-1]
+		i++
+		text += strings.TrimLeft(lines[i], " \t")
+	}
+
+	return text, i
+}
+
+// collectHeredocBody gathers lines[start:] up to (excluding) the line whose
+// trimmed content equals marker. It reports the index of the closing line
+// and whether one was found within lines.
+func collectHeredocBody(lines []string, start int, marker string) (body []string, closeIdx int, closed bool) {
+	for j := start; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == marker {
+			return body, j, true
 		}
-		lineCount++
+
+		body = append(body, lines[j])
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	return body, -1, false
+}
+
+// continuationSegmentLength reports how many lines, starting at lines[0],
+// make up one backslash-continuation chain (1 if lines[0] doesn't end in an
+// unescaped backslash), and whether that chain is fully resolved within
+// lines rather than still pending at the end of the buffer. It is used by
+// the bottom window to evict whole chains at once instead of splitting one
+// across the eviction boundary.
+func continuationSegmentLength(lines []string) (n int, resolved bool) {
+	if !trailingBackslashOdd(lines[0]) {
+		return 1, true
 	}
 
-	// Parse bottom lines from buffer
-	if s.ScanBottom {
-		// Determine which lines to scan from buffer to avoid duplicates
-		startIdx := 0
-		if s.ScanTop && lineCount <= s.MaxLines {
-			// File is shorter than or equal to MaxLines, and we already scanned from top
-			// Don't scan any lines from bottom buffer (they were already scanned)
-			return modelines, nil
-		} else if s.ScanTop && lineCount < 2*s.MaxLines {
-			// File is shorter than 2*MaxLines
-			// Skip the overlap: we already scanned first MaxLines
-			overlap := 2*s.MaxLines - lineCount
-			startIdx = s.MaxLines - overlap
+	_, endIdx := joinContinuationLines(lines, 0)
+	if endIdx == len(lines)-1 && trailingBackslashOdd(lines[endIdx]) {
+		return 0, false
+	}
+
+	return endIdx + 1, true
+}
+
+// heredocSegmentLength reports how many lines, starting at lines[0], make up
+// one heredoc block opened there, whether lines[0] is a heredoc opener at
+// all, and whether the block closes within lines rather than running past
+// the end of the buffer still open.
+func heredocSegmentLength(lines []string) (n int, isHeredoc, resolved bool) {
+	if _, marker, _, ok := findHeredocOpen(lines[0]); ok {
+		_, closeIdx, closed := collectHeredocBody(lines, 1, marker)
+		if !closed {
+			return 0, true, false
 		}
 
-		for i := startIdx; i < len(bottomBuffer); i++ {
-			if m, err := s.ScanString(bottomBuffer[i]); err == nil {
-				modelines = append(modelines, *m)
+		return closeIdx + 1, true, true
+	}
+
+	return 0, false, true
+}
+
+// frontSegmentLength reports how many lines, starting at lines[0], make up
+// the next logical unit scanLines would parse there — a heredoc block, a
+// backslash-continuation chain, or a single plain line — and whether that
+// unit is fully resolved within lines. It is used by the bottom window to
+// evict whole units at once, so a block/chain whose opening line straddles
+// the eviction boundary isn't split from the rest of its lines.
+func frontSegmentLength(lines []string) (n int, resolved bool) {
+	if n, isHeredoc, resolved := heredocSegmentLength(lines); isHeredoc {
+		return n, resolved
+	}
+
+	return continuationSegmentLength(lines)
+}
+
+// pendingHeredocMarker reports whether lines ends inside an unclosed
+// heredoc block, and if so, the marker that would close it.
+func pendingHeredocMarker(lines []string) (marker string, open bool) {
+	i := 0
+	for i < len(lines) {
+		if _, m, _, ok := findHeredocOpen(lines[i]); ok {
+			_, closeIdx, closed := collectHeredocBody(lines, i+1, m)
+			if !closed {
+				return m, true
 			}
+
+			i = closeIdx + 1
+			continue
 		}
+
+		i++
 	}
 
-	return modelines, nil
+	return "", false
 }
 
-// ScanFile extracts modelines from a file.
+// stripCommonIndent removes the shortest leading whitespace run shared by
+// all non-blank lines, used by trimmed heredoc blocks (opened with <<-MARKER).
+func stripCommonIndent(lines []string) []string {
+	minIndent := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		indent := len(l) - len(strings.TrimLeft(l, " \t"))
+		if minIndent == -1 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+
+	if minIndent <= 0 {
+		return lines
+	}
+
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if len(l) >= minIndent {
+			out[i] = l[minIndent:]
+		} else {
+			out[i] = strings.TrimLeft(l, " \t")
+		}
+	}
+
+	return out
+}
+
+// ScanFile extracts modelines from a file. If IncludeResolver is unset, one
+// is provided automatically that resolves include paths relative to path's
+// directory.
 func (s *Scanner) ScanFile(path string) ([]Modeline, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -130,7 +381,9 @@ func (s *Scanner) ScanFile(path string) ([]Modeline, error) {
 	}
 
 	defer file.Close()
-	return s.Scan(file)
+
+	effective := s.withDefaultIncludeResolver(path)
+	return effective.Scan(file)
 }
 
 // ScanString is a convenience method for extracting a modeline from a single string.
@@ -140,16 +393,24 @@ func (s *Scanner) ScanString(str string) (*Modeline, error) {
 		return nil, err
 	}
 
-	options, err := parseOptions(rest)
+	options, err := parseOptions(program, rest)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Modeline{
+	if err := s.resolveIncludeOptions(program, options); err != nil {
+		return nil, err
+	}
+
+	m := &Modeline{
 		Program: program,
 		Options: options,
 		RawLine: str,
-	}, nil
+	}
+
+	applySchema(m)
+
+	return m, nil
 }
 
 // Scan extracts modelines from the reader using the default settings.
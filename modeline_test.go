@@ -419,6 +419,34 @@ line 2
 	}
 }
 
+// TestScanTopWindowCountsEveryLine guards against readTopWindow dropping the
+// line immediately after its MaxLines window: scanner.Scan() must not be
+// called once lineCount already reached maxLines, or that line is consumed
+// from the reader but never counted, desyncing every read after it.
+func TestScanTopWindowCountsEveryLine(t *testing.T) {
+	input := `line 1
+line 2
+line 3
+line 4
+line 5
+# bottom: x=1
+`
+	s := modeline.Scanner{ScanTop: true, ScanBottom: true, MaxLines: 5}
+
+	got, err := s.Scan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	if len(got) != 1 || got[0].Options["x"] != "1" {
+		t.Fatalf("Scan() = %+v, want the bottom modeline on line 6", got)
+	}
+
+	if got[0].StartLine != 6 || got[0].EndLine != 6 {
+		t.Errorf("StartLine/EndLine = %d/%d, want 6/6", got[0].StartLine, got[0].EndLine)
+	}
+}
+
 func TestScanFile(t *testing.T) {
 	// modeline_test.txt has modelines at the top (lines 1-5) and bottom (lines 96-100)
 	// Default scanner has ScanTop=true, ScanBottom=true, MaxLines=5
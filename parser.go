@@ -48,17 +48,68 @@ func isWordChar(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
 }
 
+// findHeredocOpen scans line for the pattern: whitespace + identifier +
+// "<<" + optional "-" (trim flag) + marker, e.g. "# envctl<<END" or
+// "# envctl<<-END". It mirrors findProgram's identifier scanning but looks
+// for a heredoc opener instead of a colon.
+func findHeredocOpen(line string) (program, marker string, trim bool, ok bool) {
+	for i := 0; i < len(line); i++ {
+		if line[i] != ' ' && line[i] != '\t' {
+			continue
+		}
+
+		j := i + 1
+		for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+			j++
+		}
+
+		if j >= len(line) {
+			continue
+		}
+
+		start := j
+		for j < len(line) && isWordChar(line[j]) {
+			j++
+		}
+
+		if j == start || j+1 >= len(line) || line[j] != '<' || line[j+1] != '<' {
+			continue
+		}
+
+		program = line[start:j]
+		k := j + 2
+
+		if k < len(line) && line[k] == '-' {
+			trim = true
+			k++
+		}
+
+		markerStart := k
+		for k < len(line) && isWordChar(line[k]) {
+			k++
+		}
+
+		if k == markerStart {
+			continue
+		}
+
+		return program, line[markerStart:k], trim, true
+	}
+
+	return "", "", false, false
+}
+
 // parseOptions determines the form and extracts options from the remaining text.
-func parseOptions(rest string) (map[string]string, error) {
+func parseOptions(program, rest string) (map[string]string, error) {
 	rest = strings.TrimLeft(rest, " \t")
 
 	// Check for second form: starts with "set " or "se "
 	if strings.HasPrefix(rest, "set ") {
-		return parseSecondForm(rest[4:])
+		return parseSecondForm(program, rest[4:])
 	}
 
 	if strings.HasPrefix(rest, "se ") {
-		return parseSecondForm(rest[3:])
+		return parseSecondForm(program, rest[3:])
 	}
 
 	// Check if it ends with : (malformed second form)
@@ -67,11 +118,11 @@ func parseOptions(rest string) (map[string]string, error) {
 	}
 
 	// First form: split by whitespace and colons
-	return parseFirstForm(rest), nil
+	return parseFirstForm(program, rest), nil
 }
 
 // parseSecondForm extracts options from second form: options end at ':'.
-func parseSecondForm(rest string) (map[string]string, error) {
+func parseSecondForm(program, rest string) (map[string]string, error) {
 	// Find the closing colon
 	colonIdx := strings.Index(rest, ":")
 	if colonIdx == -1 {
@@ -80,23 +131,23 @@ func parseSecondForm(rest string) (map[string]string, error) {
 	}
 
 	optionsText := rest[:colonIdx]
-	return parseFirstForm(optionsText), nil
+	return parseFirstForm(program, optionsText), nil
 }
 
 // parseFirstForm splits text by whitespace and colons, then parses each token.
-func parseFirstForm(text string) map[string]string {
+func parseFirstForm(program, text string) map[string]string {
 	options := make(map[string]string)
 
-	// Split by whitespace and colons
+	// Split by whitespace, colons and (for heredoc blocks) newlines
 	tokens := strings.FieldsFunc(text, func(r rune) bool {
-		return r == ' ' || r == '\t' || r == ':'
+		return r == ' ' || r == '\t' || r == ':' || r == '\n'
 	})
 
 	for _, token := range tokens {
 		if token == "" {
 			continue
 		}
-		key, value := parseOption(token)
+		key, value := parseOption(program, token)
 		if key != "" {
 			options[key] = value
 		}
@@ -106,7 +157,7 @@ func parseFirstForm(text string) map[string]string {
 }
 
 // parseOption parses a single option token into key and value.
-func parseOption(token string) (key, value string) {
+func parseOption(program, token string) (key, value string) {
 	// Check for key=value
 	if key, val, found := strings.Cut(token, "="); found {
 		return key, val
@@ -114,7 +165,16 @@ func parseOption(token string) (key, value string) {
 
 	// Check for noXXX (boolean negation)
 	if strings.HasPrefix(token, "no") && len(token) > 2 {
-		return token[2:], "false"
+		negated := token[2:]
+
+		// If a schema is registered for this program, only treat "noXXX" as
+		// boolean negation when "XXX" is declared there as OptionBool; otherwise the
+		// token is a literal key (e.g. an option genuinely named "nofoo").
+		if known, hasSchema := schemaHasOption(program, negated); hasSchema && !known {
+			return token, "true"
+		}
+
+		return negated, "false"
 	}
 
 	// Plain token (implicit true)
@@ -0,0 +1,148 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OptionType describes the expected Go type of a schema-declared option.
+type OptionType int
+
+const (
+	OptionString OptionType = iota
+	OptionBool
+	OptionInt
+	OptionEnum
+	OptionDuration
+)
+
+// OptionSchema describes a single known option for a program: its type,
+// whether it must be present, its accepted values (for OptionEnum), and
+// the default applied when the option is absent from a modeline.
+type OptionSchema struct {
+	Type     OptionType
+	Required bool
+	Default  any
+	Enum     []string // valid values when Type == OptionEnum
+}
+
+// Schema declares the set of options a program understands, so that
+// ScanString and Scan can coerce a Modeline's string Options into typed
+// values instead of leaving every caller to reinvent the conversion.
+type Schema struct {
+	Options map[string]OptionSchema
+}
+
+var (
+	schemaMu sync.RWMutex
+	schemas  = make(map[string]Schema)
+)
+
+// RegisterSchema associates a Schema with a program identifier (e.g. "vim"
+// or "envctl"). Subsequent calls to ScanString/Scan that encounter a
+// modeline for that program populate Modeline.Typed, Modeline.Warnings and
+// Modeline.Errors according to the schema.
+func RegisterSchema(program string, s Schema) {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	schemas[program] = s
+}
+
+// lookupSchema returns the schema registered for program, if any.
+func lookupSchema(program string) (Schema, bool) {
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+	s, ok := schemas[program]
+	return s, ok
+}
+
+// applySchema populates m.Typed, m.Warnings and m.Errors from the schema
+// registered for m.Program, if one exists. It is a no-op when no schema is
+// registered for the program.
+func applySchema(m *Modeline) {
+	schema, ok := lookupSchema(m.Program)
+	if !ok {
+		return
+	}
+
+	typed := make(map[string]any, len(schema.Options))
+
+	for key, optSchema := range schema.Options {
+		raw, present := m.Options[key]
+		if !present {
+			if optSchema.Required {
+				m.Errors = append(m.Errors, fmt.Sprintf("missing required option %q", key))
+				continue
+			}
+
+			if optSchema.Default != nil {
+				typed[key] = optSchema.Default
+			}
+
+			continue
+		}
+
+		val, err := coerceOption(raw, optSchema)
+		if err != nil {
+			m.Errors = append(m.Errors, fmt.Sprintf("option %q: %v", key, err))
+			continue
+		}
+
+		typed[key] = val
+	}
+
+	for key, raw := range m.Options {
+		if _, known := schema.Options[key]; !known {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("unknown option %q", key))
+			typed[key] = raw
+		}
+	}
+
+	m.Typed = typed
+}
+
+// coerceOption converts a raw string option value into the type declared
+// by optSchema.
+func coerceOption(raw string, optSchema OptionSchema) (any, error) {
+	switch optSchema.Type {
+	case OptionBool:
+		return strconv.ParseBool(raw)
+	case OptionInt:
+		return strconv.Atoi(raw)
+	case OptionDuration:
+		return time.ParseDuration(raw)
+	case OptionEnum:
+		for _, v := range optSchema.Enum {
+			if v == raw {
+				return raw, nil
+			}
+		}
+
+		return nil, fmt.Errorf("value %q not in enum %v", raw, optSchema.Enum)
+	case OptionString:
+		return raw, nil
+	default:
+		return raw, nil
+	}
+}
+
+// schemaHasOption reports whether program has a schema registered and,
+// if so, whether key is declared within it as a boolean option. It is used
+// by the parser to decide whether a "noXXX" token is implicit-boolean
+// negation of a real boolean option or should be kept as the literal key
+// "noXXX" — a "noXXX" token for an option declared with a non-bool type
+// (e.g. OptionInt) is not negation, since there is no boolean to negate.
+func schemaHasOption(program, key string) (known, hasSchema bool) {
+	schema, ok := lookupSchema(program)
+	if !ok {
+		return false, false
+	}
+
+	optSchema, present := schema.Options[key]
+	return present && optSchema.Type == OptionBool, true
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Kyle Huggins
+// SPDX-License-Identifier: BSD-3-Clause
+
+package modeline_test
+
+import (
+	"testing"
+
+	"github.com/hugginsio/modeline"
+)
+
+func TestScanStringTyped(t *testing.T) {
+	modeline.RegisterSchema("envctl", modeline.Schema{
+		Options: map[string]modeline.OptionSchema{
+			"gsm_project": {Type: modeline.OptionInt, Required: true},
+			"provider":    {Type: modeline.OptionEnum, Enum: []string{"gsm", "vault"}, Default: "gsm"},
+			"verbose":     {Type: modeline.OptionBool, Default: false},
+		},
+	})
+
+	got, err := modeline.ScanString("# envctl: provider=gsm gsm_project=526782592 region=us-east1")
+	if err != nil {
+		t.Fatalf("ScanString() error = %v", err)
+	}
+
+	if got.Typed["gsm_project"] != 526782592 {
+		t.Errorf("Typed[gsm_project] = %v, want %v", got.Typed["gsm_project"], 526782592)
+	}
+
+	if got.Typed["provider"] != "gsm" {
+		t.Errorf("Typed[provider] = %v, want %q", got.Typed["provider"], "gsm")
+	}
+
+	if len(got.Warnings) != 1 || got.Warnings[0] != `unknown option "region"` {
+		t.Errorf("Warnings = %v, want a single warning about %q", got.Warnings, "region")
+	}
+
+	if len(got.Errors) != 0 {
+		t.Errorf("Errors = %v, want none", got.Errors)
+	}
+}
+
+func TestScanStringTypedMissingRequired(t *testing.T) {
+	modeline.RegisterSchema("envctl", modeline.Schema{
+		Options: map[string]modeline.OptionSchema{
+			"gsm_project": {Type: modeline.OptionInt, Required: true},
+		},
+	})
+
+	got, err := modeline.ScanString("# envctl: provider=gsm")
+	if err != nil {
+		t.Fatalf("ScanString() error = %v", err)
+	}
+
+	if len(got.Errors) != 1 {
+		t.Fatalf("Errors = %v, want one error about the missing required option", got.Errors)
+	}
+}
+
+func TestParseOptionSchemaAwareNegation(t *testing.T) {
+	modeline.RegisterSchema("widget", modeline.Schema{
+		Options: map[string]modeline.OptionSchema{
+			"autosave": {Type: modeline.OptionBool},
+		},
+	})
+
+	got, err := modeline.ScanString("# widget: noautosave nofrobnicate")
+	if err != nil {
+		t.Fatalf("ScanString() error = %v", err)
+	}
+
+	if got.Options["autosave"] != "false" {
+		t.Errorf(`Options["autosave"] = %q, want "false"`, got.Options["autosave"])
+	}
+
+	if v, ok := got.Options["nofrobnicate"]; !ok || v != "true" {
+		t.Errorf(`Options["nofrobnicate"] = %q, %v, want "true", true`, v, ok)
+	}
+}
+
+func TestParseOptionSchemaAwareNegationNonBoolOption(t *testing.T) {
+	modeline.RegisterSchema("widget", modeline.Schema{
+		Options: map[string]modeline.OptionSchema{
+			"autosave": {Type: modeline.OptionBool},
+			"retries":  {Type: modeline.OptionInt},
+		},
+	})
+
+	got, err := modeline.ScanString("# widget: noretries")
+	if err != nil {
+		t.Fatalf("ScanString() error = %v", err)
+	}
+
+	if v, ok := got.Options["noretries"]; !ok || v != "true" {
+		t.Errorf(`Options["noretries"] = %q, %v, want "true", true: "retries" is declared non-bool, so "noretries" isn't negation`, v, ok)
+	}
+
+	if _, ok := got.Options["retries"]; ok {
+		t.Error(`Options["retries"] is set, want "noretries" left as a literal key instead of negating "retries"`)
+	}
+}